@@ -0,0 +1,98 @@
+package main
+
+import (
+	"sync"
+
+	"gitlab.com/NebulousLabs/Sia/types"
+)
+
+// A hostAnnouncementEvent is a single host announcement processed by the
+// consensus set, along with the block height it was confirmed at and its
+// encoded (HostAnnouncement, Signature) pair, ready to write to a client.
+type hostAnnouncementEvent struct {
+	Height types.BlockHeight
+	Data   []byte
+}
+
+// A hostBroadcaster fans out newly-processed host announcements to any
+// number of subscribers. It retains a bounded amount of history so that a
+// client reconnecting with ?since=<block> can replay what it missed, and it
+// bounds each subscriber's backlog so a slow or disconnected client cannot
+// grow server memory without limit.
+//
+// newSHARD is passed hosts.Publish as a callback (see main) and is expected
+// to call it for each HostAnnouncement it applies, alongside the existing
+// per-host persistence used by handlerHost.
+type hostBroadcaster struct {
+	mu         sync.Mutex
+	subs       map[int]chan hostAnnouncementEvent
+	nextSubID  int
+	history    []hostAnnouncementEvent
+	maxHistory int
+	maxBacklog int
+}
+
+func newHostBroadcaster(maxHistory, maxBacklog int) *hostBroadcaster {
+	return &hostBroadcaster{
+		subs:       make(map[int]chan hostAnnouncementEvent),
+		maxHistory: maxHistory,
+		maxBacklog: maxBacklog,
+	}
+}
+
+// Publish records a newly-processed host announcement in the replay history
+// and delivers it to every current subscriber, dropping it for any
+// subscriber whose backlog is full. height is the block height the
+// announcement was confirmed at, and data is the same encoded
+// (HostAnnouncement, Signature) pair returned by HostAnnouncement(pubkey).
+func (b *hostBroadcaster) Publish(height types.BlockHeight, data []byte) {
+	ev := hostAnnouncementEvent{Height: height, Data: data}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.history = append(b.history, ev)
+	if len(b.history) > b.maxHistory {
+		b.history = b.history[len(b.history)-b.maxHistory:]
+	}
+	for _, ch := range b.subs {
+		select {
+		case ch <- ev:
+		default:
+			// subscriber backlog is full; drop the event rather than block
+			// the publisher or grow memory unboundedly.
+		}
+	}
+}
+
+// Subscribe returns a channel of retained events at or after since,
+// followed by any events published while the subscriber is connected. The
+// channel has room for the whole replay plus maxBacklog further events. The
+// returned unsubscribe function must be called to release the subscription.
+func (b *hostBroadcaster) Subscribe(since types.BlockHeight) (<-chan hostAnnouncementEvent, func()) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var replay []hostAnnouncementEvent
+	for _, ev := range b.history {
+		if ev.Height >= since {
+			replay = append(replay, ev)
+		}
+	}
+	ch := make(chan hostAnnouncementEvent, len(replay)+b.maxBacklog)
+	for _, ev := range replay {
+		ch <- ev
+	}
+
+	id := b.nextSubID
+	b.nextSubID++
+	b.subs[id] = ch
+
+	unsubscribe := func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		delete(b.subs, id)
+		close(ch)
+	}
+	return ch, unsubscribe
+}
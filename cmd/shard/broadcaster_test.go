@@ -0,0 +1,89 @@
+package main
+
+import (
+	"testing"
+
+	"gitlab.com/NebulousLabs/Sia/types"
+)
+
+func TestHostBroadcasterReplaysHistorySinceHeight(t *testing.T) {
+	b := newHostBroadcaster(10, 10)
+	b.Publish(1, []byte("one"))
+	b.Publish(2, []byte("two"))
+	b.Publish(3, []byte("three"))
+
+	events, unsubscribe := b.Subscribe(2)
+	defer unsubscribe()
+
+	for _, want := range []types.BlockHeight{2, 3} {
+		select {
+		case ev := <-events:
+			if ev.Height != want {
+				t.Fatalf("got height %v, want %v", ev.Height, want)
+			}
+		default:
+			t.Fatalf("expected a replayed event for height %v", want)
+		}
+	}
+	select {
+	case ev := <-events:
+		t.Fatalf("unexpected extra event: %+v", ev)
+	default:
+	}
+}
+
+func TestHostBroadcasterTrimsHistory(t *testing.T) {
+	b := newHostBroadcaster(2, 10)
+	b.Publish(1, []byte("one"))
+	b.Publish(2, []byte("two"))
+	b.Publish(3, []byte("three"))
+
+	events, unsubscribe := b.Subscribe(0)
+	defer unsubscribe()
+
+	for _, want := range []types.BlockHeight{2, 3} {
+		select {
+		case ev := <-events:
+			if ev.Height != want {
+				t.Fatalf("got height %v, want %v", ev.Height, want)
+			}
+		default:
+			t.Fatalf("expected a replayed event for height %v", want)
+		}
+	}
+}
+
+func TestHostBroadcasterDropsBacklogWhenFull(t *testing.T) {
+	b := newHostBroadcaster(10, 1)
+	events, unsubscribe := b.Subscribe(0)
+	defer unsubscribe()
+
+	// the subscriber's backlog can hold only one event; the second publish
+	// should be dropped for this subscriber rather than block the publisher
+	b.Publish(1, []byte("one"))
+	b.Publish(2, []byte("two"))
+
+	select {
+	case ev := <-events:
+		if ev.Height != 1 {
+			t.Fatalf("got height %v, want 1", ev.Height)
+		}
+	default:
+		t.Fatal("expected the first event to be delivered")
+	}
+	select {
+	case ev := <-events:
+		t.Fatalf("expected the second event to be dropped, got %+v", ev)
+	default:
+	}
+}
+
+func TestHostBroadcasterUnsubscribeClosesChannel(t *testing.T) {
+	b := newHostBroadcaster(10, 10)
+	events, unsubscribe := b.Subscribe(0)
+	unsubscribe()
+
+	if _, ok := <-events; ok {
+		t.Fatal("expected the events channel to be closed after unsubscribe")
+	}
+}
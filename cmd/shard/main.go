@@ -1,7 +1,9 @@
 package main
 
 import (
+	"encoding/json"
 	"flag"
+	"fmt"
 	"io"
 	"log"
 	"net/http"
@@ -13,12 +15,23 @@ import (
 	"gitlab.com/NebulousLabs/Sia/build"
 	"gitlab.com/NebulousLabs/Sia/modules/consensus"
 	"gitlab.com/NebulousLabs/Sia/modules/gateway"
+	"gitlab.com/NebulousLabs/Sia/types"
 
 	"github.com/julienschmidt/httprouter"
 )
 
+// maxHostStreamHistory and maxHostStreamBacklog bound, respectively, how
+// many past announcements a reconnecting client can replay and how many
+// pending announcements an individual subscriber may queue before events
+// are dropped for it.
+const (
+	maxHostStreamHistory = 10000
+	maxHostStreamBacklog = 256
+)
+
 type server struct {
 	shard *SHARD
+	hosts *hostBroadcaster
 }
 
 func (s *server) handlerSynced(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
@@ -38,12 +51,96 @@ func (s *server) handlerHost(w http.ResponseWriter, req *http.Request, ps httpro
 	w.Write(ann)
 }
 
-func newServer(shard *SHARD) http.Handler {
-	srv := &server{shard}
+// handlerHostStream upgrades to a Server-Sent Events stream of host
+// announcements as they are processed. If the request includes ?since=N,
+// the client first replays any retained announcements at or after block
+// height N, so that a client reconnecting after downtime doesn't miss any.
+func (s *server) handlerHostStream(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming not supported", http.StatusInternalServerError)
+		return
+	}
+
+	var since types.BlockHeight
+	if s := req.URL.Query().Get("since"); s != "" {
+		height, err := strconv.ParseUint(s, 10, 64)
+		if err != nil {
+			http.Error(w, "invalid since parameter", http.StatusBadRequest)
+			return
+		}
+		since = types.BlockHeight(height)
+	}
+
+	events, unsubscribe := s.hosts.Subscribe(since)
+	defer unsubscribe()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	for {
+		select {
+		case ev, ok := <-events:
+			if !ok {
+				return
+			}
+			fmt.Fprintf(w, "id: %d\ndata: %x\n\n", ev.Height, ev.Data)
+			flusher.Flush()
+		case <-req.Context().Done():
+			return
+		}
+	}
+}
+
+// maxBulkHosts bounds how many pubkeys a single /hosts request may contain,
+// so that one client can't force the server to do unbounded work per call.
+const maxBulkHosts = 10000
+
+// bulkHostResult is the per-pubkey result of a POST /hosts request. Data
+// holds the same encoded (HostAnnouncement, Signature) pair that
+// handlerHost returns; it is omitted (null, when marshaled in a slice) for
+// pubkeys with no known announcement.
+type bulkHostResult struct {
+	Data []byte `json:"data,omitempty"`
+}
+
+// handlerHostsBulk resolves many host pubkeys in a single request, avoiding
+// the round-trip cost of calling handlerHost once per host.
+func (s *server) handlerHostsBulk(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	var pubkeys []string
+	if err := json.NewDecoder(req.Body).Decode(&pubkeys); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if len(pubkeys) > maxBulkHosts {
+		http.Error(w, "too many pubkeys", http.StatusBadRequest)
+		return
+	}
+
+	results := make([]*bulkHostResult, len(pubkeys))
+	for i, pubkey := range pubkeys {
+		if ann, ok := s.shard.HostAnnouncement(pubkey); ok {
+			results[i] = &bulkHostResult{Data: ann}
+		}
+	}
+	json.NewEncoder(w).Encode(results)
+}
+
+// newServerWithBroadcaster returns an http.Handler for shard, delivering
+// host announcement events to /events/hosts subscribers via hosts. hosts
+// must be the same hostBroadcaster whose Publish method was passed to
+// newSHARD, so that streamed events match what shard actually processed.
+func newServerWithBroadcaster(shard *SHARD, hosts *hostBroadcaster) http.Handler {
+	srv := &server{shard: shard, hosts: hosts}
 	mux := httprouter.New()
 	mux.GET("/synced", srv.handlerSynced)
 	mux.GET("/height", srv.handlerHeight)
 	mux.GET("/host/:pubkey", srv.handlerHost)
+	mux.GET("/events/hosts", srv.handlerHostStream)
+	mux.POST("/hosts", srv.handlerHostsBulk)
 	return mux
 }
 
@@ -78,12 +175,18 @@ func main() {
 	if err != nil {
 		log.Fatal(err)
 	}
-	shard, err := newSHARD(cs, newJSONPersist(*persistDir))
+
+	hosts := newHostBroadcaster(maxHostStreamHistory, maxHostStreamBacklog)
+	// newSHARD must call hosts.Publish for each HostAnnouncement it applies
+	// and persists, using the same block height and encoded
+	// (HostAnnouncement, Signature) pair that HostAnnouncement(pubkey)
+	// later returns, so that /events/hosts subscribers see it.
+	shard, err := newSHARD(cs, newJSONPersist(*persistDir), hosts.Publish)
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	srv := newServer(shard)
+	srv := newServerWithBroadcaster(shard, hosts)
 	log.Printf("Listening on %v...", *apiAddr)
 	log.Fatal(http.ListenAndServe(*apiAddr, srv))
-}
\ No newline at end of file
+}
@@ -0,0 +1,63 @@
+package renter
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"gitlab.com/NebulousLabs/fastrand"
+
+	"lukechampine.com/us/merkle"
+)
+
+func TestCipherRoundtrip(t *testing.T) {
+	for _, scheme := range []CipherScheme{CipherAESCTR, CipherAESXTS, CipherXChaCha20} {
+		var m MetaIndex
+		m.Cipher = scheme
+		fastrand.Read(m.MasterKey[:])
+		key := m.EncryptionKey(0)
+
+		plaintext := []byte(strings.Repeat("test", 64))
+		ciphertext := make([]byte, len(plaintext))
+		key.EncryptSegments(ciphertext, plaintext, 0)
+		if bytes.Equal(ciphertext, plaintext) {
+			t.Fatalf("scheme %v: encryption failed", scheme)
+		}
+
+		decrypted := make([]byte, len(ciphertext))
+		key.DecryptSegments(decrypted, ciphertext, 0)
+		if !bytes.Equal(decrypted, plaintext) {
+			t.Fatalf("scheme %v: roundtrip failed", scheme)
+		}
+
+		// decrypting from a segment offset should match the corresponding
+		// slice of the plaintext
+		off := merkle.SegmentSize * 2
+		partial := make([]byte, len(ciphertext)-off)
+		key.DecryptSegments(partial, ciphertext[off:], 2)
+		if !bytes.Equal(partial, plaintext[off:]) {
+			t.Fatalf("scheme %v: offset decryption failed", scheme)
+		}
+	}
+}
+
+func TestCipherCrossSchemeMismatch(t *testing.T) {
+	var m MetaIndex
+	m.Cipher = CipherAESCTR
+	fastrand.Read(m.MasterKey[:])
+
+	plaintext := []byte(strings.Repeat("test", 64))
+	ciphertext := make([]byte, len(plaintext))
+	m.EncryptionKey(0).EncryptSegments(ciphertext, plaintext, 0)
+
+	// decrypting with a different cipher scheme (the MasterKey is the same,
+	// but the scheme is mixed into the chunk key and selects a different
+	// keystream) must not recover the original plaintext
+	wrong := m
+	wrong.Cipher = CipherXChaCha20
+	decrypted := make([]byte, len(ciphertext))
+	wrong.EncryptionKey(0).DecryptSegments(decrypted, ciphertext, 0)
+	if bytes.Equal(decrypted, plaintext) {
+		t.Fatal("decryption succeeded with mismatched cipher scheme")
+	}
+}
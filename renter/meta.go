@@ -0,0 +1,138 @@
+package renter
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/binary"
+
+	"golang.org/x/crypto/chacha20"
+	"golang.org/x/crypto/xts"
+
+	"gitlab.com/NebulousLabs/Sia/crypto"
+
+	"lukechampine.com/us/hostdb"
+	"lukechampine.com/us/merkle"
+)
+
+// A CipherScheme identifies the algorithm used to encrypt a file's segments.
+type CipherScheme uint8
+
+// Supported cipher schemes. CipherAESCTR is the default, used by files
+// written before Cipher was introduced.
+const (
+	CipherAESCTR CipherScheme = iota
+	CipherAESXTS
+	CipherXChaCha20
+)
+
+// MetaIndex is the metadata describing an uploaded file: its size, its
+// shard layout, and the key material needed to encrypt and decrypt it.
+type MetaIndex struct {
+	Version   string
+	Filesize  int64
+	MinShards int
+	MasterKey [32]byte
+	Cipher    CipherScheme
+	Hosts     []hostdb.HostPublicKey
+}
+
+// A SegmentCipher encrypts and decrypts the segments of a single chunk,
+// supporting random access starting at any segment offset.
+type SegmentCipher struct {
+	scheme CipherScheme
+	key    [32]byte
+}
+
+// EncryptionKey derives the SegmentCipher used to encrypt and decrypt the
+// chunk at the given index. Each chunk is encrypted under a distinct key
+// derived from the file's MasterKey, so that no key material is shared
+// across chunks.
+func (m *MetaIndex) EncryptionKey(chunkIndex int) SegmentCipher {
+	return SegmentCipher{
+		scheme: m.Cipher,
+		key:    deriveChunkKey(m.MasterKey, m.Cipher, chunkIndex),
+	}
+}
+
+func deriveChunkKey(masterKey [32]byte, scheme CipherScheme, chunkIndex int) [32]byte {
+	buf := make([]byte, 0, len(masterKey)+9)
+	buf = append(buf, masterKey[:]...)
+	buf = append(buf, byte(scheme))
+	var idx [8]byte
+	binary.LittleEndian.PutUint64(idx[:], uint64(chunkIndex))
+	buf = append(buf, idx[:]...)
+	return crypto.HashBytes(buf)
+}
+
+// EncryptSegments encrypts src into dst, treating src as a sequence of
+// merkle.SegmentSize-byte segments starting at segmentIndex. dst and src
+// may overlap exactly.
+func (k SegmentCipher) EncryptSegments(dst, src []byte, segmentIndex int64) {
+	k.xorSegments(dst, src, segmentIndex, true)
+}
+
+// DecryptSegments decrypts src into dst, treating src as a sequence of
+// merkle.SegmentSize-byte segments starting at segmentIndex. dst and src
+// may overlap exactly.
+func (k SegmentCipher) DecryptSegments(dst, src []byte, segmentIndex int64) {
+	k.xorSegments(dst, src, segmentIndex, false)
+}
+
+func (k SegmentCipher) xorSegments(dst, src []byte, segmentIndex int64, encrypt bool) {
+	switch k.scheme {
+	case CipherAESXTS:
+		k.xtsSegments(dst, src, segmentIndex, encrypt)
+	case CipherXChaCha20:
+		// stream ciphers are their own inverse, so decryption is the same
+		// XOR operation as encryption.
+		k.chacha20Segments(dst, src, segmentIndex)
+	default:
+		k.ctrSegments(dst, src, segmentIndex)
+	}
+}
+
+// chacha20Segments XORs src with the XChaCha20 keystream, seeking to the
+// block that begins segmentIndex so that decryption can start at an
+// arbitrary segment without processing the segments before it. ChaCha20's
+// counter advances one block per 64 bytes, which evenly divides
+// merkle.SegmentSize.
+func (k SegmentCipher) chacha20Segments(dst, src []byte, segmentIndex int64) {
+	const chachaBlockSize = 64
+	c, err := chacha20.NewUnauthenticatedCipher(k.key[:], make([]byte, chacha20.NonceSizeX))
+	if err != nil {
+		panic(err) // key is always 32 bytes
+	}
+	c.SetCounter(uint32(segmentIndex * (merkle.SegmentSize / chachaBlockSize)))
+	c.XORKeyStream(dst, src)
+}
+
+// ctrSegments XORs src with an AES-256-CTR keystream, seeking to the block
+// that begins segmentIndex by initializing the CTR counter directly rather
+// than discarding keystream. This is the legacy scheme used by files
+// written before Cipher was introduced, so it must remain real AES-CTR for
+// those files to keep decrypting correctly.
+func (k SegmentCipher) ctrSegments(dst, src []byte, segmentIndex int64) {
+	block, err := aes.NewCipher(k.key[:])
+	if err != nil {
+		panic(err) // key is always 32 bytes
+	}
+	const blocksPerSegment = merkle.SegmentSize / aes.BlockSize
+	var iv [aes.BlockSize]byte
+	binary.BigEndian.PutUint64(iv[aes.BlockSize-8:], uint64(segmentIndex*blocksPerSegment))
+	cipher.NewCTR(block, iv[:]).XORKeyStream(dst, src)
+}
+
+func (k SegmentCipher) xtsSegments(dst, src []byte, segmentIndex int64, encrypt bool) {
+	c, err := xts.NewCipher(aes.NewCipher, k.key[:])
+	if err != nil {
+		panic(err)
+	}
+	for off := 0; off+merkle.SegmentSize <= len(src); off += merkle.SegmentSize {
+		sector := uint64(segmentIndex) + uint64(off/merkle.SegmentSize)
+		if encrypt {
+			c.Encrypt(dst[off:off+merkle.SegmentSize], src[off:off+merkle.SegmentSize], sector)
+		} else {
+			c.Decrypt(dst[off:off+merkle.SegmentSize], src[off:off+merkle.SegmentSize], sector)
+		}
+	}
+}
@@ -0,0 +1,43 @@
+package renterutil
+
+import (
+	"testing"
+
+	"gitlab.com/NebulousLabs/Sia/modules"
+	"gitlab.com/NebulousLabs/Sia/types"
+)
+
+func TestTxnInputsSpentElsewhere(t *testing.T) {
+	outputID := types.SiacoinOutputID{1}
+	txn := types.Transaction{
+		SiacoinInputs: []types.SiacoinInput{{ParentID: outputID}},
+	}
+
+	stillUnspent := []modules.UnspentOutput{{ID: types.OutputID(outputID)}}
+	if txnInputsSpentElsewhere(txn, stillUnspent) {
+		t.Fatal("input is still in the unspent set, should not be reported as spent elsewhere")
+	}
+
+	spentElsewhere := []modules.UnspentOutput{{ID: types.OutputID(types.SiacoinOutputID{2})}}
+	if !txnInputsSpentElsewhere(txn, spentElsewhere) {
+		t.Fatal("input is absent from the unspent set, should be reported as spent elsewhere")
+	}
+
+	// a transaction with no siacoin inputs (e.g. a file contract revision)
+	// cannot have been replaced this way
+	if txnInputsSpentElsewhere(types.Transaction{}, spentElsewhere) {
+		t.Fatal("a transaction with no siacoin inputs should never be reported as spent elsewhere")
+	}
+}
+
+func TestTransactionInPool(t *testing.T) {
+	txn := types.Transaction{MinerFees: []types.Currency{types.NewCurrency64(1)}}
+	other := types.Transaction{MinerFees: []types.Currency{types.NewCurrency64(2)}}
+
+	if transactionInPool(txn, []types.Transaction{other}) {
+		t.Fatal("txn is not among pending, should not be reported as in the pool")
+	}
+	if !transactionInPool(txn, []types.Transaction{other, txn}) {
+		t.Fatal("txn is among pending, should be reported as in the pool")
+	}
+}
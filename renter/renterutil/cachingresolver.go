@@ -0,0 +1,186 @@
+package renterutil
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"sync"
+	"time"
+
+	"lukechampine.com/us/hostdb"
+	"lukechampine.com/us/renter"
+
+	"gitlab.com/NebulousLabs/Sia/modules"
+	"gitlab.com/NebulousLabs/Sia/types"
+)
+
+// A cacheEntry records a previously-resolved host address, or the fact that
+// resolution failed with errNoHostAnnouncement (a negative entry).
+type cacheEntry struct {
+	Addr     modules.NetAddress `json:"addr"`
+	Negative bool               `json:"negative"`
+	Expiry   time.Time          `json:"expiry"`
+}
+
+func (e cacheEntry) expired(now time.Time) bool { return now.After(e.Expiry) }
+
+// A CachingResolver wraps a renter.HostKeyResolver with an on-disk cache, so
+// that repeated lookups of the same host avoid a synchronous round trip.
+// Entries are served from the cache immediately and refreshed asynchronously
+// once they go stale. Lookups that fail with errNoHostAnnouncement are
+// cached too, for their own (typically shorter) period, to avoid hammering a
+// resolver for hosts that have no announcement; other errors are not cached.
+type CachingResolver struct {
+	resolver renter.HostKeyResolver
+	path     string
+	ttl      time.Duration
+	negTTL   time.Duration
+
+	mu      sync.Mutex
+	entries map[hostdb.HostPublicKey]cacheEntry
+}
+
+// NewCachingResolver returns a CachingResolver wrapping resolver. If path
+// names an existing snapshot (written by a prior call to Export), the cache
+// is warmed from it. ResolveHostKey never writes to path itself; callers
+// that want the cache to survive a restart must call Export themselves,
+// e.g. on a timer or at shutdown. ttl and negTTL control how long positive
+// and negative entries are served before being refreshed.
+func NewCachingResolver(resolver renter.HostKeyResolver, path string, ttl, negTTL time.Duration) (*CachingResolver, error) {
+	cr := &CachingResolver{
+		resolver: resolver,
+		path:     path,
+		ttl:      ttl,
+		negTTL:   negTTL,
+		entries:  make(map[hostdb.HostPublicKey]cacheEntry),
+	}
+	if err := cr.Import(path); err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	return cr, nil
+}
+
+// ResolveHostKey resolves pubkey, preferring a cached entry if one exists.
+// A stale entry is still returned, but triggers an asynchronous refresh so
+// that the next call sees an up-to-date result.
+func (cr *CachingResolver) ResolveHostKey(pubkey hostdb.HostPublicKey) (modules.NetAddress, error) {
+	cr.mu.Lock()
+	entry, ok := cr.entries[pubkey]
+	cr.mu.Unlock()
+
+	if ok {
+		if entry.expired(time.Now()) {
+			go cr.refresh(pubkey)
+		}
+		if entry.Negative {
+			return "", errNoHostAnnouncement
+		}
+		return entry.Addr, nil
+	}
+	return cr.refresh(pubkey)
+}
+
+// ChainHeight returns the wrapped resolver's chain height; it is not cached.
+func (cr *CachingResolver) ChainHeight() (types.BlockHeight, error) {
+	return cr.resolver.ChainHeight()
+}
+
+// Synced returns the wrapped resolver's sync status; it is not cached.
+func (cr *CachingResolver) Synced() (bool, error) {
+	return cr.resolver.Synced()
+}
+
+func (cr *CachingResolver) refresh(pubkey hostdb.HostPublicKey) (modules.NetAddress, error) {
+	addr, err := cr.resolver.ResolveHostKey(pubkey)
+	if err != nil && err != errNoHostAnnouncement {
+		return "", err
+	}
+	entry := cacheEntry{Addr: addr, Negative: err == errNoHostAnnouncement}
+	if entry.Negative {
+		entry.Expiry = time.Now().Add(cr.negTTL)
+	} else {
+		entry.Expiry = time.Now().Add(cr.ttl)
+	}
+
+	cr.mu.Lock()
+	cr.entries[pubkey] = entry
+	cr.mu.Unlock()
+
+	if entry.Negative {
+		return "", errNoHostAnnouncement
+	}
+	return addr, nil
+}
+
+// Prune removes all expired entries from the cache and returns the number
+// of entries removed.
+func (cr *CachingResolver) Prune() int {
+	cr.mu.Lock()
+	defer cr.mu.Unlock()
+	now := time.Now()
+	removed := 0
+	for pubkey, entry := range cr.entries {
+		if entry.expired(now) {
+			delete(cr.entries, pubkey)
+			removed++
+		}
+	}
+	return removed
+}
+
+// Entries returns the public keys currently present in the cache, including
+// stale ones.
+func (cr *CachingResolver) Entries() []hostdb.HostPublicKey {
+	cr.mu.Lock()
+	defer cr.mu.Unlock()
+	pubkeys := make([]hostdb.HostPublicKey, 0, len(cr.entries))
+	for pubkey := range cr.entries {
+		pubkeys = append(pubkeys, pubkey)
+	}
+	return pubkeys
+}
+
+// snapshot is the on-disk representation of a CachingResolver's cache.
+type snapshot struct {
+	Entries map[hostdb.HostPublicKey]cacheEntry `json:"entries"`
+}
+
+// Export writes the current cache contents to path as JSON, so that a fresh
+// renter can later seed its cache via Import without contacting a resolver.
+func (cr *CachingResolver) Export(path string) error {
+	cr.mu.Lock()
+	entries := make(map[hostdb.HostPublicKey]cacheEntry, len(cr.entries))
+	for pubkey, entry := range cr.entries {
+		entries[pubkey] = entry
+	}
+	cr.mu.Unlock()
+
+	js, err := json.MarshalIndent(snapshot{Entries: entries}, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, js, 0666)
+}
+
+// Import loads a snapshot previously written by Export and merges it into
+// the cache, ignoring any entries it already has. NewCachingResolver calls
+// it to warm the cache at startup.
+func (cr *CachingResolver) Import(path string) error {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var snap snapshot
+	if err := json.Unmarshal(data, &snap); err != nil {
+		return err
+	}
+
+	cr.mu.Lock()
+	defer cr.mu.Unlock()
+	for pubkey, entry := range snap.Entries {
+		if _, ok := cr.entries[pubkey]; !ok {
+			cr.entries[pubkey] = entry
+		}
+	}
+	return nil
+}
@@ -0,0 +1,122 @@
+package renterutil
+
+import (
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"lukechampine.com/us/hostdb"
+
+	"gitlab.com/NebulousLabs/Sia/modules"
+	"gitlab.com/NebulousLabs/Sia/types"
+)
+
+// countingResolver is a renter.HostKeyResolver that records how many times
+// ResolveHostKey was called, so tests can tell whether CachingResolver
+// actually served a cached entry instead of hitting the backend.
+type countingResolver struct {
+	mu    sync.Mutex
+	calls int
+	addr  modules.NetAddress
+	err   error
+}
+
+func (r *countingResolver) ResolveHostKey(hostdb.HostPublicKey) (modules.NetAddress, error) {
+	r.mu.Lock()
+	r.calls++
+	r.mu.Unlock()
+	return r.addr, r.err
+}
+
+func (r *countingResolver) ChainHeight() (types.BlockHeight, error) { return 0, nil }
+func (r *countingResolver) Synced() (bool, error)                   { return true, nil }
+
+func (r *countingResolver) callCount() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.calls
+}
+
+func TestCachingResolverServesCacheAndRefreshesStale(t *testing.T) {
+	backend := &countingResolver{addr: "1.2.3.4:9982"}
+	cr, err := NewCachingResolver(backend, filepath.Join(t.TempDir(), "cache.json"), 20*time.Millisecond, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if addr, err := cr.ResolveHostKey("pubkey"); err != nil || addr != backend.addr {
+		t.Fatalf("got (%v, %v), want (%v, nil)", addr, err, backend.addr)
+	}
+	if calls := backend.callCount(); calls != 1 {
+		t.Fatalf("expected 1 call to populate the cache, got %v", calls)
+	}
+
+	// within the TTL, ResolveHostKey should be served from cache
+	if addr, err := cr.ResolveHostKey("pubkey"); err != nil || addr != backend.addr {
+		t.Fatalf("got (%v, %v), want (%v, nil)", addr, err, backend.addr)
+	}
+	if calls := backend.callCount(); calls != 1 {
+		t.Fatalf("expected cached entry to avoid a second call, got %v calls", calls)
+	}
+
+	// once the entry is stale, ResolveHostKey still returns the stale value
+	// immediately, but triggers a refresh in the background
+	time.Sleep(30 * time.Millisecond)
+	if addr, err := cr.ResolveHostKey("pubkey"); err != nil || addr != backend.addr {
+		t.Fatalf("got (%v, %v), want (%v, nil)", addr, err, backend.addr)
+	}
+	deadline := time.Now().Add(time.Second)
+	for backend.callCount() < 2 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if calls := backend.callCount(); calls < 2 {
+		t.Fatalf("expected a stale entry to trigger a background refresh, got %v calls", calls)
+	}
+}
+
+func TestCachingResolverNegativeCaching(t *testing.T) {
+	backend := &countingResolver{err: errNoHostAnnouncement}
+	cr, err := NewCachingResolver(backend, filepath.Join(t.TempDir(), "cache.json"), time.Minute, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := cr.ResolveHostKey("pubkey"); err != errNoHostAnnouncement {
+		t.Fatalf("got err %v, want errNoHostAnnouncement", err)
+	}
+	if _, err := cr.ResolveHostKey("pubkey"); err != errNoHostAnnouncement {
+		t.Fatalf("got err %v, want errNoHostAnnouncement", err)
+	}
+	if calls := backend.callCount(); calls != 1 {
+		t.Fatalf("expected the negative result to be cached, got %v calls", calls)
+	}
+}
+
+func TestCachingResolverExportImportRoundtrip(t *testing.T) {
+	backend := &countingResolver{addr: "1.2.3.4:9982"}
+	cr, err := NewCachingResolver(backend, filepath.Join(t.TempDir(), "cache.json"), time.Minute, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := cr.ResolveHostKey("hostA"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := cr.ResolveHostKey("hostB"); err != nil {
+		t.Fatal(err)
+	}
+
+	snapshotPath := filepath.Join(t.TempDir(), "snapshot.json")
+	if err := cr.Export(snapshotPath); err != nil {
+		t.Fatal(err)
+	}
+
+	fresh, err := NewCachingResolver(&countingResolver{}, snapshotPath, time.Minute, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pubkeys := fresh.Entries()
+	if len(pubkeys) != 2 {
+		t.Fatalf("expected 2 entries warmed from the snapshot, got %v", len(pubkeys))
+	}
+}
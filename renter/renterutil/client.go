@@ -1,7 +1,10 @@
 package renterutil
 
 import (
+	"bufio"
+	"bytes"
 	"context"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -10,6 +13,7 @@ import (
 	"net/http"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"lukechampine.com/us/hostdb"
@@ -23,6 +27,13 @@ import (
 
 var errNoHostAnnouncement = errors.New("host announcement not found")
 
+// errInvalidSignature is returned by ResolveHostKey implementations that
+// verify a signed response (such as SHARDClient) when that verification
+// fails. It is a sentinel so that callers in this package, like
+// MultiResolver, can use errors.Is to distinguish a bad signature from a
+// transient failure without depending on error message text.
+var errInvalidSignature = errors.New("invalid signature")
+
 // SiadClient wraps the siad API client. It satisfies the proto.Wallet,
 // proto.TransactionPool, and renter.HostKeyResolver interfaces. The
 // proto.Wallet methods require that the wallet is unlocked.
@@ -60,6 +71,218 @@ func (c *SiadClient) FeeEstimate() (minFee, maxFee types.Currency, err error) {
 	return tfg.Minimum, tfg.Maximum, err
 }
 
+// A V2Transaction is a placeholder for the v2 transaction format introduced
+// by the walletd transition; siad does not yet expose it over its API. Once
+// it does, this alias should be replaced with the real type.
+type V2Transaction = types.Transaction
+
+// AcceptTransactionSetV2 submits a transaction set to the transaction pool
+// using the v2 broadcast shape (separate legacy and v2 transaction slices,
+// mirroring walletd's TxpoolBroadcastRequest), where it will be broadcast
+// to other peers. It posts to siad's /tpool/broadcast route and returns any
+// error siad reports, including when siad does not serve that route.
+func (c *SiadClient) AcceptTransactionSetV2(txnSet []types.Transaction, v2TxnSet []V2Transaction) error {
+	if len(txnSet) == 0 && len(v2TxnSet) == 0 {
+		return errors.New("empty transaction set")
+	}
+	body, err := json.Marshal(struct {
+		Transactions   []types.Transaction `json:"transactions"`
+		V2Transactions []V2Transaction     `json:"v2transactions"`
+	}{txnSet, v2TxnSet})
+	if err != nil {
+		return err
+	}
+	_, err = c.siad.Post("/tpool/broadcast", string(body))
+	return err
+}
+
+// BumpFee constructs and broadcasts a child transaction that spends one of
+// txn's own outputs and pays newFee, so that, under child-pays-for-parent
+// relay rules, txn is more likely to confirm even if its own fee is stuck
+// below the current fee market. It fails if none of txn's outputs are both
+// known to the wallet (i.e. currently unspent and spendable) and large
+// enough to cover newFee.
+func (c *SiadClient) BumpFee(txn types.Transaction, newFee types.Currency) (types.Transaction, error) {
+	outputs, err := c.UnspentOutputs()
+	if err != nil {
+		return types.Transaction{}, err
+	}
+	unspent := make(map[types.SiacoinOutputID]*modules.UnspentOutput, len(outputs))
+	for i, o := range outputs {
+		if o.FundType == types.SpecifierSiacoinOutput {
+			unspent[types.SiacoinOutputID(o.ID)] = &outputs[i]
+		}
+	}
+	var input *modules.UnspentOutput
+	for i := range txn.SiacoinOutputs {
+		if o, ok := unspent[txn.SiacoinOutputID(uint64(i))]; ok && o.Value.Cmp(newFee) >= 0 {
+			input = o
+			break
+		}
+	}
+	if input == nil {
+		return types.Transaction{}, errors.New("txn has no wallet-spendable output large enough to cover the bumped fee")
+	}
+
+	refundAddr, err := c.NewWalletAddress()
+	if err != nil {
+		return types.Transaction{}, err
+	}
+	unlockConditions, err := c.UnlockConditions(input.UnlockHash)
+	if err != nil {
+		return types.Transaction{}, err
+	}
+
+	child := types.Transaction{
+		SiacoinInputs: []types.SiacoinInput{{
+			ParentID:         types.SiacoinOutputID(input.ID),
+			UnlockConditions: unlockConditions,
+		}},
+		SiacoinOutputs: []types.SiacoinOutput{{
+			Value:      input.Value.Sub(newFee),
+			UnlockHash: refundAddr,
+		}},
+		MinerFees: []types.Currency{newFee},
+		TransactionSignatures: []types.TransactionSignature{{
+			ParentID:      crypto.Hash(input.ID),
+			CoveredFields: types.FullCoveredFields,
+		}},
+	}
+	if err := c.SignTransaction(&child, []crypto.Hash{crypto.Hash(input.ID)}); err != nil {
+		return types.Transaction{}, err
+	}
+	if err := c.AcceptTransactionSet([]types.Transaction{txn, child}); err != nil {
+		return types.Transaction{}, err
+	}
+	return child, nil
+}
+
+// A ConfirmationStatus describes the state of a transaction submitted via
+// WatchTransaction.
+type ConfirmationStatus int
+
+// Confirmation statuses returned by WatchTransaction.
+const (
+	// TransactionConfirmed indicates the transaction was confirmed in a block.
+	TransactionConfirmed ConfirmationStatus = iota
+	// TransactionReplaced indicates one of the transaction's inputs was
+	// spent by a different transaction before this one confirmed.
+	TransactionReplaced
+	// TransactionDropped indicates the transaction is no longer in the
+	// transaction pool and was never confirmed or replaced.
+	TransactionDropped
+)
+
+// A ConfirmationEvent reports a change in the status of a transaction being
+// watched by WatchTransaction.
+type ConfirmationEvent struct {
+	Status ConfirmationStatus
+	Err    error
+}
+
+// watchPollInterval is how often WatchTransaction polls siad for the status
+// of a watched transaction.
+const watchPollInterval = 10 * time.Second
+
+// WatchTransaction polls siad for the confirmation status of txn, emitting
+// a ConfirmationEvent when it confirms, is replaced (one of its inputs is
+// spent by a different transaction), or is dropped from the transaction
+// pool without confirming. It stops polling, and closes the returned
+// channel, once it emits an event or ctx is canceled.
+func (c *SiadClient) WatchTransaction(ctx context.Context, txn types.Transaction) (<-chan ConfirmationEvent, error) {
+	events := make(chan ConfirmationEvent, 1)
+	go func() {
+		defer close(events)
+		ticker := time.NewTicker(watchPollInterval)
+		defer ticker.Stop()
+		var seenInPool bool
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+
+			tcg, err := c.siad.TransactionPoolConfirmedGet(txn.ID())
+			if err != nil {
+				events <- ConfirmationEvent{Err: err}
+				return
+			}
+			if tcg.Confirmed {
+				events <- ConfirmationEvent{Status: TransactionConfirmed}
+				return
+			}
+
+			outputs, err := c.UnspentOutputs()
+			if err != nil {
+				events <- ConfirmationEvent{Err: err}
+				return
+			}
+			if txnInputsSpentElsewhere(txn, outputs) {
+				events <- ConfirmationEvent{Status: TransactionReplaced}
+				return
+			}
+
+			pending, err := c.PoolTransactions()
+			if err != nil {
+				events <- ConfirmationEvent{Err: err}
+				return
+			}
+			switch {
+			case transactionInPool(txn, pending):
+				seenInPool = true
+			case seenInPool:
+				// txn was relayed into the pool at some point but is no
+				// longer there, hasn't confirmed, and its inputs are still
+				// unspent elsewhere, so it wasn't replaced either: it was
+				// simply evicted, most likely because its fee fell below
+				// what the pool currently requires.
+				events <- ConfirmationEvent{Status: TransactionDropped}
+				return
+			}
+		}
+	}()
+	return events, nil
+}
+
+// txnInputsSpentElsewhere reports whether none of txn's siacoin inputs
+// appear among the wallet's current unspent outputs, i.e. they were
+// consumed by some other transaction. A transaction with no siacoin inputs
+// is never reported.
+func txnInputsSpentElsewhere(txn types.Transaction, unspent []modules.UnspentOutput) bool {
+	if len(txn.SiacoinInputs) == 0 {
+		return false
+	}
+	unspentIDs := make(map[types.SiacoinOutputID]struct{}, len(unspent))
+	for _, o := range unspent {
+		unspentIDs[types.SiacoinOutputID(o.ID)] = struct{}{}
+	}
+	for _, in := range txn.SiacoinInputs {
+		if _, ok := unspentIDs[in.ParentID]; ok {
+			return false
+		}
+	}
+	return true
+}
+
+// transactionInPool reports whether txn appears among pending, the current
+// contents of the transaction pool.
+func transactionInPool(txn types.Transaction, pending []types.Transaction) bool {
+	for _, t := range pending {
+		if t.ID() == txn.ID() {
+			return true
+		}
+	}
+	return false
+}
+
+// PoolTransactions returns the set of transactions currently pending in the
+// transaction pool.
+func (c *SiadClient) PoolTransactions() ([]types.Transaction, error) {
+	tpg, err := c.siad.TransactionPoolTransactionsGet()
+	return tpg.Transactions, err
+}
+
 // NewWalletAddress returns a new address generated by the wallet.
 func (c *SiadClient) NewWalletAddress() (types.UnlockHash, error) {
 	wag, err := c.siad.WalletAddressGet()
@@ -112,6 +335,37 @@ func (c *SiadClient) ResolveHostKey(pubkey hostdb.HostPublicKey) (modules.NetAdd
 	return hhg.Entry.NetAddress, err
 }
 
+// siadBulkResolveWorkers bounds the number of concurrent HostDbHostsGet
+// requests ResolveHostKeys issues against siad.
+const siadBulkResolveWorkers = 32
+
+// ResolveHostKeys resolves many host public keys, issuing one siad request
+// per key, at most siadBulkResolveWorkers at a time. It satisfies
+// renter.BulkResolver.
+func (c *SiadClient) ResolveHostKeys(pubkeys []hostdb.HostPublicKey) ([]modules.NetAddress, []error) {
+	addrs := make([]modules.NetAddress, len(pubkeys))
+	errs := make([]error, len(pubkeys))
+
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < siadBulkResolveWorkers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				addrs[i], errs[i] = c.ResolveHostKey(pubkeys[i])
+			}
+		}()
+	}
+	for i := range pubkeys {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	return addrs, errs
+}
+
 // Scan scans the specified host.
 func (c *SiadClient) Scan(pubkey hostdb.HostPublicKey) (hostdb.ScannedHost, error) {
 	hhg, err := c.siad.HostDbHostsGet(pubkey.SiaPublicKey())
@@ -194,12 +448,140 @@ func (c *SHARDClient) ResolveHostKey(pubkey hostdb.HostPublicKey) (modules.NetAd
 
 	// verify signature
 	if crypto.VerifyHash(crypto.HashObject(ha), pubkey.Ed25519(), sig) != nil {
-		return "", errors.New("invalid signature")
+		return "", errInvalidSignature
 	}
 
 	return ha.NetAddress, err
 }
 
+// bulkHostResult mirrors cmd/shard's response shape for POST /hosts: the
+// encoded (HostAnnouncement, Signature) pair for a resolved pubkey, or a
+// null entry for one with no known announcement.
+type bulkHostResult struct {
+	Data []byte `json:"data,omitempty"`
+}
+
+// ResolveHostKeys resolves many host public keys in a single request to the
+// SHARD server. It satisfies renter.BulkResolver.
+func (c *SHARDClient) ResolveHostKeys(pubkeys []hostdb.HostPublicKey) ([]modules.NetAddress, []error) {
+	addrs := make([]modules.NetAddress, len(pubkeys))
+	errs := make([]error, len(pubkeys))
+
+	body, err := json.Marshal(pubkeys)
+	if err != nil {
+		for i := range errs {
+			errs[i] = err
+		}
+		return addrs, errs
+	}
+	resp, err := http.Post(fmt.Sprintf("http://%v/hosts", c.addr), "application/json", bytes.NewReader(body))
+	if err != nil {
+		for i := range errs {
+			errs[i] = err
+		}
+		return addrs, errs
+	}
+	defer resp.Body.Close()
+	if !(200 <= resp.StatusCode && resp.StatusCode <= 299) {
+		errString, _ := ioutil.ReadAll(resp.Body)
+		err := errors.New(string(errString))
+		for i := range errs {
+			errs[i] = err
+		}
+		return addrs, errs
+	}
+
+	var results []*bulkHostResult
+	if err := json.NewDecoder(resp.Body).Decode(&results); err != nil {
+		for i := range errs {
+			errs[i] = err
+		}
+		return addrs, errs
+	}
+	if len(results) != len(pubkeys) {
+		err := fmt.Errorf("server returned %v results for %v pubkeys", len(results), len(pubkeys))
+		for i := range errs {
+			errs[i] = err
+		}
+		return addrs, errs
+	}
+	for i, r := range results {
+		if r == nil {
+			errs[i] = errNoHostAnnouncement
+			continue
+		}
+		var ha modules.HostAnnouncement
+		var sig crypto.Signature
+		if err := encoding.NewDecoder(bytes.NewReader(r.Data), encoding.DefaultAllocLimit).DecodeAll(&ha, &sig); err != nil {
+			errs[i] = err
+			continue
+		}
+		if crypto.VerifyHash(crypto.HashObject(ha), pubkeys[i].Ed25519(), sig) != nil {
+			errs[i] = errInvalidSignature
+			continue
+		}
+		addrs[i] = ha.NetAddress
+	}
+	return addrs, errs
+}
+
+// SubscribeHosts subscribes to the SHARD server's stream of processed host
+// announcements, returning a channel that receives them in the order the
+// server sends them. The server first replays the announcements it retains
+// at or after block height since. Announcements that fail to decode or carry
+// an invalid signature are skipped; the block height of each announcement
+// is not passed on. The channel is closed when ctx is canceled, the
+// connection is lost, or the stream contains data that is not valid hex.
+func (c *SHARDClient) SubscribeHosts(ctx context.Context, since types.BlockHeight) (<-chan modules.HostAnnouncement, error) {
+	url := fmt.Sprintf("http://%v/events/hosts?since=%d", c.addr, since)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if !(200 <= resp.StatusCode && resp.StatusCode <= 299) {
+		errString, _ := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		return nil, errors.New(string(errString))
+	}
+
+	anns := make(chan modules.HostAnnouncement)
+	go func() {
+		defer close(anns)
+		defer resp.Body.Close()
+		scanner := bufio.NewScanner(resp.Body)
+		for scanner.Scan() {
+			line := scanner.Text()
+			if !strings.HasPrefix(line, "data: ") {
+				continue
+			}
+			data, err := hex.DecodeString(strings.TrimPrefix(line, "data: "))
+			if err != nil {
+				return
+			}
+			var ha modules.HostAnnouncement
+			var sig crypto.Signature
+			if err := encoding.NewDecoder(bytes.NewReader(data), encoding.DefaultAllocLimit).DecodeAll(&ha, &sig); err != nil {
+				continue
+			}
+			// verify signature, as ResolveHostKey does
+			pk := hostdb.HostPublicKey(ha.PublicKey.String())
+			if crypto.VerifyHash(crypto.HashObject(ha), pk.Ed25519(), sig) != nil {
+				continue
+			}
+			select {
+			case anns <- ha:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return anns, nil
+}
+
 // NewSHARDClient returns a SHARDClient that communicates with the SHARD
 // server at the specified address.
 func NewSHARDClient(addr string) *SHARDClient {
@@ -0,0 +1,46 @@
+package renterutil
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"lukechampine.com/us/hostdb"
+)
+
+func TestSHARDClientResolveHostKeysLengthMismatch(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// respond with fewer results than pubkeys requested, simulating a
+		// malformed or buggy server
+		json.NewEncoder(w).Encode([]*bulkHostResult{{Data: []byte("x")}})
+	}))
+	defer srv.Close()
+
+	c := NewSHARDClient(strings.TrimPrefix(srv.URL, "http://"))
+	pubkeys := []hostdb.HostPublicKey{"hostA", "hostB", "hostC"}
+	addrs, errs := c.ResolveHostKeys(pubkeys)
+
+	if len(addrs) != len(pubkeys) || len(errs) != len(pubkeys) {
+		t.Fatalf("expected length-matched results, got %v addrs and %v errs for %v pubkeys", len(addrs), len(errs), len(pubkeys))
+	}
+	for i, err := range errs {
+		if err == nil {
+			t.Fatalf("entry %v: expected an error on result-count mismatch, got nil", i)
+		}
+	}
+}
+
+func TestSHARDClientResolveHostKeysUnknownHost(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode([]*bulkHostResult{nil})
+	}))
+	defer srv.Close()
+
+	c := NewSHARDClient(strings.TrimPrefix(srv.URL, "http://"))
+	_, errs := c.ResolveHostKeys([]hostdb.HostPublicKey{"hostA"})
+	if len(errs) != 1 || errs[0] != errNoHostAnnouncement {
+		t.Fatalf("got errs %v, want [errNoHostAnnouncement]", errs)
+	}
+}
@@ -0,0 +1,353 @@
+package renterutil
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"lukechampine.com/us/hostdb"
+	"lukechampine.com/us/renter"
+
+	"gitlab.com/NebulousLabs/Sia/modules"
+	"gitlab.com/NebulousLabs/Sia/types"
+)
+
+// A ResolvePolicy determines how a MultiResolver combines the responses of
+// its backends.
+type ResolvePolicy struct {
+	kind resolvePolicyKind
+	n    int
+}
+
+type resolvePolicyKind int
+
+const (
+	policyFirst resolvePolicyKind = iota
+	policyFastest
+	policyQuorum
+)
+
+// First returns the response of the first backend (in configuration order)
+// to resolve successfully. It waits until every backend has answered or the
+// timeout has passed; backends that failed or did not answer in time are
+// skipped.
+func First() ResolvePolicy { return ResolvePolicy{kind: policyFirst} }
+
+// Fastest returns the response of whichever backend answers first,
+// regardless of configuration order.
+func Fastest() ResolvePolicy { return ResolvePolicy{kind: policyFastest} }
+
+// Quorum returns a response only once at least n backends agree on it. It
+// panics if n is less than 1.
+func Quorum(n int) ResolvePolicy {
+	if n < 1 {
+		panic("renterutil: quorum size must be at least 1")
+	}
+	return ResolvePolicy{kind: policyQuorum, n: n}
+}
+
+// BackendHealth reports recent behavior of one of a MultiResolver's
+// backends.
+type BackendHealth struct {
+	LastError     error
+	Latency       time.Duration
+	AgreementRate float64 // fraction of quorum resolutions this backend agreed with
+}
+
+type backendState struct {
+	resolver renter.HostKeyResolver
+	health   BackendHealth
+	queries  int
+	agrees   int
+	dropped  bool
+}
+
+// A MultiResolver combines multiple renter.HostKeyResolver backends (siad,
+// SHARD, or any other implementation) into a single resolver, trading a
+// single backend's availability for trust-minimized agreement among many.
+// It satisfies renter.HostKeyResolver.
+type MultiResolver struct {
+	mu       sync.Mutex
+	backends []*backendState
+	policy   ResolvePolicy
+	timeout  time.Duration
+}
+
+// NewMultiResolver returns a MultiResolver that queries each of the supplied
+// backends concurrently, combining their responses according to policy.
+// Each query is bounded by timeout.
+func NewMultiResolver(timeout time.Duration, policy ResolvePolicy, backends ...renter.HostKeyResolver) *MultiResolver {
+	states := make([]*backendState, len(backends))
+	for i, b := range backends {
+		states[i] = &backendState{resolver: b}
+	}
+	return &MultiResolver{
+		backends: states,
+		policy:   policy,
+		timeout:  timeout,
+	}
+}
+
+// Health returns a snapshot of each backend's health metrics, in the order
+// the backends were supplied to NewMultiResolver.
+func (mr *MultiResolver) Health() []BackendHealth {
+	mr.mu.Lock()
+	defer mr.mu.Unlock()
+	health := make([]BackendHealth, len(mr.backends))
+	for i, b := range mr.backends {
+		health[i] = b.health
+	}
+	return health
+}
+
+// activeIndices returns the indices, in mr.backends, of backends that have
+// not been dropped.
+func (mr *MultiResolver) activeIndices() []int {
+	mr.mu.Lock()
+	defer mr.mu.Unlock()
+	active := make([]int, 0, len(mr.backends))
+	for i, b := range mr.backends {
+		if !b.dropped {
+			active = append(active, i)
+		}
+	}
+	return active
+}
+
+type resolveResult struct {
+	index int
+	addr  modules.NetAddress
+	err   error
+}
+
+func (mr *MultiResolver) dispatch(ctx context.Context, query func(renter.HostKeyResolver) (modules.NetAddress, error)) []resolveResult {
+	ctx, cancel := context.WithTimeout(ctx, mr.timeout)
+	defer cancel()
+
+	active := mr.activeIndices()
+	results := make(chan resolveResult, len(active))
+	for _, i := range active {
+		go func(i int) {
+			start := time.Now()
+			addr, err := query(mr.backends[i].resolver)
+			mr.recordLatency(i, time.Since(start), err)
+			select {
+			case results <- resolveResult{i, addr, err}:
+			case <-ctx.Done():
+			}
+		}(i)
+	}
+
+	var collected []resolveResult
+	for range active {
+		select {
+		case r := <-results:
+			collected = append(collected, r)
+		case <-ctx.Done():
+			return collected
+		}
+	}
+	return collected
+}
+
+type heightResult struct {
+	index  int
+	height types.BlockHeight
+	err    error
+}
+
+func (mr *MultiResolver) dispatchChainHeight(ctx context.Context) []heightResult {
+	ctx, cancel := context.WithTimeout(ctx, mr.timeout)
+	defer cancel()
+
+	active := mr.activeIndices()
+	results := make(chan heightResult, len(active))
+	for _, i := range active {
+		go func(i int) {
+			h, err := mr.backends[i].resolver.ChainHeight()
+			select {
+			case results <- heightResult{i, h, err}:
+			case <-ctx.Done():
+			}
+		}(i)
+	}
+
+	var collected []heightResult
+	for range active {
+		select {
+		case r := <-results:
+			collected = append(collected, r)
+		case <-ctx.Done():
+			return collected
+		}
+	}
+	return collected
+}
+
+type syncedResult struct {
+	index  int
+	synced bool
+	err    error
+}
+
+func (mr *MultiResolver) dispatchSynced(ctx context.Context) []syncedResult {
+	ctx, cancel := context.WithTimeout(ctx, mr.timeout)
+	defer cancel()
+
+	active := mr.activeIndices()
+	results := make(chan syncedResult, len(active))
+	for _, i := range active {
+		go func(i int) {
+			s, err := mr.backends[i].resolver.Synced()
+			select {
+			case results <- syncedResult{i, s, err}:
+			case <-ctx.Done():
+			}
+		}(i)
+	}
+
+	var collected []syncedResult
+	for range active {
+		select {
+		case r := <-results:
+			collected = append(collected, r)
+		case <-ctx.Done():
+			return collected
+		}
+	}
+	return collected
+}
+
+func (mr *MultiResolver) recordLatency(i int, d time.Duration, err error) {
+	mr.mu.Lock()
+	defer mr.mu.Unlock()
+	b := mr.backends[i]
+	b.health.Latency = d
+	b.health.LastError = err
+	if errors.Is(err, errInvalidSignature) {
+		b.dropped = true
+	}
+}
+
+func (mr *MultiResolver) recordAgreement(i int, agreed bool) {
+	mr.mu.Lock()
+	defer mr.mu.Unlock()
+	b := mr.backends[i]
+	b.queries++
+	if agreed {
+		b.agrees++
+	}
+	b.health.AgreementRate = float64(b.agrees) / float64(b.queries)
+}
+
+// ResolveHostKey resolves a host public key to that host's most recently
+// announced network address, combining responses from each backend
+// according to mr's policy.
+func (mr *MultiResolver) ResolveHostKey(pubkey hostdb.HostPublicKey) (modules.NetAddress, error) {
+	results := mr.dispatch(context.Background(), func(r renter.HostKeyResolver) (modules.NetAddress, error) {
+		return r.ResolveHostKey(pubkey)
+	})
+
+	switch mr.policy.kind {
+	case policyFirst:
+		ordered := append([]resolveResult(nil), results...)
+		sort.Slice(ordered, func(i, j int) bool { return ordered[i].index < ordered[j].index })
+		for _, r := range ordered {
+			if r.err == nil {
+				return r.addr, nil
+			}
+		}
+		if len(ordered) == 0 {
+			return "", errors.New("renterutil: no backend responded")
+		}
+		return "", ordered[0].err
+
+	case policyFastest:
+		for _, r := range results {
+			if r.err == nil {
+				return r.addr, nil
+			}
+		}
+		if len(results) == 0 {
+			return "", errors.New("renterutil: no backend responded")
+		}
+		return "", results[0].err
+
+	case policyQuorum:
+		counts := make(map[modules.NetAddress]int)
+		var best modules.NetAddress
+		bestCount := 0
+		for _, r := range results {
+			if r.err != nil {
+				continue
+			}
+			counts[r.addr]++
+			if counts[r.addr] > bestCount {
+				best, bestCount = r.addr, counts[r.addr]
+			}
+		}
+		for _, r := range results {
+			mr.recordAgreement(r.index, r.err == nil && r.addr == best)
+		}
+		if bestCount < mr.policy.n {
+			return "", errors.New("renterutil: quorum not reached")
+		}
+		return best, nil
+
+	default:
+		panic("renterutil: unknown resolve policy")
+	}
+}
+
+// ChainHeight returns the median block height reported by the backends,
+// querying them concurrently and bounding the wait by mr's timeout.
+func (mr *MultiResolver) ChainHeight() (types.BlockHeight, error) {
+	results := mr.dispatchChainHeight(context.Background())
+
+	var heights []types.BlockHeight
+	var lastErr error
+	for _, r := range results {
+		if r.err != nil {
+			lastErr = r.err
+			continue
+		}
+		heights = append(heights, r.height)
+	}
+	if len(heights) == 0 {
+		return 0, lastErr
+	}
+	sort.Slice(heights, func(i, j int) bool { return heights[i] < heights[j] })
+	return heights[len(heights)/2], nil
+}
+
+// Synced returns true only if a quorum of backends (n for Quorum(n)
+// policies, otherwise a simple majority) report that they are synced. Like
+// ChainHeight, backends are queried concurrently and the wait is bounded by
+// mr's timeout.
+func (mr *MultiResolver) Synced() (bool, error) {
+	results := mr.dispatchSynced(context.Background())
+
+	need := len(mr.activeIndices())/2 + 1
+	if mr.policy.kind == policyQuorum {
+		need = mr.policy.n
+	}
+
+	var synced, total int
+	var lastErr error
+	for _, r := range results {
+		if r.err != nil {
+			lastErr = r.err
+			continue
+		}
+		total++
+		if r.synced {
+			synced++
+		}
+	}
+	if total == 0 {
+		return false, lastErr
+	}
+	return synced >= need, nil
+}
@@ -0,0 +1,119 @@
+package renterutil
+
+import (
+	"testing"
+	"time"
+
+	"lukechampine.com/us/hostdb"
+
+	"gitlab.com/NebulousLabs/Sia/modules"
+	"gitlab.com/NebulousLabs/Sia/types"
+)
+
+// fakeResolver is a renter.HostKeyResolver with fully scriptable behavior,
+// used to exercise MultiResolver's dispatch and combination logic without a
+// real backend.
+type fakeResolver struct {
+	addr  modules.NetAddress
+	err   error
+	delay time.Duration
+
+	height    types.BlockHeight
+	heightErr error
+	synced    bool
+	syncedErr error
+}
+
+func (f *fakeResolver) ResolveHostKey(hostdb.HostPublicKey) (modules.NetAddress, error) {
+	if f.delay > 0 {
+		time.Sleep(f.delay)
+	}
+	return f.addr, f.err
+}
+
+func (f *fakeResolver) ChainHeight() (types.BlockHeight, error) { return f.height, f.heightErr }
+func (f *fakeResolver) Synced() (bool, error)                   { return f.synced, f.syncedErr }
+
+func TestMultiResolverFirstPrefersConfigurationOrder(t *testing.T) {
+	slow := &fakeResolver{addr: "1.2.3.4:9982", delay: 50 * time.Millisecond}
+	fast := &fakeResolver{addr: "5.6.7.8:9982"}
+
+	mr := NewMultiResolver(time.Second, First(), slow, fast)
+	addr, err := mr.ResolveHostKey("pubkey")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if addr != slow.addr {
+		t.Fatalf("First should return the first backend in configuration order (%v), got %v", slow.addr, addr)
+	}
+}
+
+func TestMultiResolverFastestIgnoresConfigurationOrder(t *testing.T) {
+	slow := &fakeResolver{addr: "1.2.3.4:9982", delay: 50 * time.Millisecond}
+	fast := &fakeResolver{addr: "5.6.7.8:9982"}
+
+	mr := NewMultiResolver(time.Second, Fastest(), slow, fast)
+	addr, err := mr.ResolveHostKey("pubkey")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if addr != fast.addr {
+		t.Fatalf("Fastest should return whichever backend answers first (%v), got %v", fast.addr, addr)
+	}
+}
+
+func TestMultiResolverFirstFallsThroughOnError(t *testing.T) {
+	failing := &fakeResolver{err: errNoHostAnnouncement}
+	ok := &fakeResolver{addr: "5.6.7.8:9982", delay: 10 * time.Millisecond}
+
+	mr := NewMultiResolver(time.Second, First(), failing, ok)
+	addr, err := mr.ResolveHostKey("pubkey")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if addr != ok.addr {
+		t.Fatalf("expected fallback to second backend (%v), got %v", ok.addr, addr)
+	}
+}
+
+func TestMultiResolverQuorum(t *testing.T) {
+	a := &fakeResolver{addr: "1.1.1.1:9982"}
+	b := &fakeResolver{addr: "1.1.1.1:9982"}
+	c := &fakeResolver{addr: "2.2.2.2:9982"}
+
+	mr := NewMultiResolver(time.Second, Quorum(2), a, b, c)
+	addr, err := mr.ResolveHostKey("pubkey")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if addr != a.addr {
+		t.Fatalf("expected quorum address %v, got %v", a.addr, addr)
+	}
+
+	mr = NewMultiResolver(time.Second, Quorum(3), a, b, c)
+	if _, err := mr.ResolveHostKey("pubkey"); err == nil {
+		t.Fatal("expected quorum of 3 to fail when only 2 backends agree")
+	}
+}
+
+func TestMultiResolverDropsBackendOnInvalidSignature(t *testing.T) {
+	bad := &fakeResolver{err: errInvalidSignature}
+	good := &fakeResolver{addr: "5.6.7.8:9982"}
+
+	mr := NewMultiResolver(time.Second, Fastest(), bad, good)
+	if _, err := mr.ResolveHostKey("pubkey"); err != nil {
+		t.Fatal(err)
+	}
+	if len(mr.activeIndices()) != 1 {
+		t.Fatalf("expected the backend with an invalid signature to be dropped, active: %v", mr.activeIndices())
+	}
+
+	// the dropped backend should no longer be queried
+	addr, err := mr.ResolveHostKey("pubkey")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if addr != good.addr {
+		t.Fatalf("expected only the remaining backend to be queried, got %v", addr)
+	}
+}
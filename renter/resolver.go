@@ -0,0 +1,25 @@
+package renter
+
+import (
+	"lukechampine.com/us/hostdb"
+
+	"gitlab.com/NebulousLabs/Sia/modules"
+	"gitlab.com/NebulousLabs/Sia/types"
+)
+
+// A HostKeyResolver resolves a host's public key to that host's most
+// recently announced network address.
+type HostKeyResolver interface {
+	ResolveHostKey(pubkey hostdb.HostPublicKey) (modules.NetAddress, error)
+	ChainHeight() (types.BlockHeight, error)
+	Synced() (bool, error)
+}
+
+// A BulkResolver resolves many host public keys in a single call. A
+// HostKeyResolver implementation may optionally satisfy BulkResolver;
+// callers with many pubkeys to resolve should type-assert for it to take
+// the fast path, falling back to per-key ResolveHostKey when it is not
+// implemented.
+type BulkResolver interface {
+	ResolveHostKeys(pubkeys []hostdb.HostPublicKey) ([]modules.NetAddress, []error)
+}